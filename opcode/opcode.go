@@ -0,0 +1,53 @@
+/*
+ * Copyright 2018 De-labtory
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package opcode
+
+// Type identifies a single koa VM instruction in the raw bytecode stream.
+type Type uint8
+
+const (
+	Add Type = iota
+	Mul
+	Sub
+	Div
+	Mod
+	LT
+	GT
+	EQ
+	NOT
+	Pop
+	Push
+	Mload
+	Mstore
+	Jump
+	Jumpi
+	Jumpdest
+	Sdiv
+	Smod
+	Slt
+	Sgt
+	Iszero
+	And
+	Or
+	Xor
+	Shl
+	Shr
+	Sar
+	Call
+	Return
+	Revert
+)
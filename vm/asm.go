@@ -0,0 +1,202 @@
+/*
+ * Copyright 2018 De-labtory
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vm
+
+import "github.com/DE-labtory/koa/opcode"
+
+// hexer is implemented by anything that can be encoded back into its raw
+// byte form in the bytecode stream, e.g. an opCode or a Data operand.
+type hexer interface {
+	hex() []uint8
+}
+
+// Data wraps a raw operand value embedded in the bytecode stream, e.g. the
+// 1- to wordBytes-byte operand that follows a Push instruction's length byte.
+type Data struct {
+	raw []byte
+}
+
+func (d Data) hex() []uint8 {
+	return d.raw
+}
+
+// asmReader lets an opCode step through the decoded instruction stream while
+// it executes, including jumping the cursor to a validated JUMPDEST.
+type asmReader interface {
+	next() hexer
+	Seek(pc int) error
+	Pos() int
+}
+
+// assembly is the linear, decoded form of a contract's raw bytecode, produced
+// by disassemble. jumpdests holds the set of code indexes that are valid
+// jump targets, i.e. positions of a jumpdest opCode.
+type assembly struct {
+	code      []hexer
+	pc        int
+	jumpdests map[int]bool
+
+	// jumped is set by Seek and consumed by Execute, so Execute can tell a
+	// jump/jumpi that moved the cursor apart from an opCode like push that
+	// also advances the cursor as a side effect of reading its own operand.
+	jumped bool
+}
+
+// cur returns the element at the current cursor position, or nil once the stream is exhausted.
+func (a *assembly) cur() hexer {
+	if a.pc < 0 || a.pc >= len(a.code) {
+		return nil
+	}
+
+	return a.code[a.pc]
+}
+
+// next advances the cursor and returns the following element, or nil once the stream is exhausted.
+func (a *assembly) next() hexer {
+	a.pc++
+	return a.cur()
+}
+
+// Pos returns the current cursor position.
+func (a *assembly) Pos() int {
+	return a.pc
+}
+
+// Seek moves the cursor to pc, rejecting any target that isn't a validated JUMPDEST.
+func (a *assembly) Seek(pc int) error {
+	if pc < 0 || pc >= len(a.code) || !a.jumpdests[pc] {
+		return ErrInvalidJumpDest
+	}
+
+	a.pc = pc
+	a.jumped = true
+	return nil
+}
+
+// consumeJumped reports whether Seek moved the cursor since the last call,
+// resetting the flag. Execute uses this to distinguish an actual jump from
+// an opCode like push merely advancing the cursor to read its own operand.
+func (a *assembly) consumeJumped() bool {
+	jumped := a.jumped
+	a.jumped = false
+	return jumped
+}
+
+// opCodeByHex resolves a single raw opcode byte to its opCode implementation.
+func opCodeByHex(b byte) (opCode, bool) {
+	switch opcode.Type(b) {
+	case opcode.Add:
+		return add{}, true
+	case opcode.Mul:
+		return mul{}, true
+	case opcode.Sub:
+		return sub{}, true
+	case opcode.Div:
+		return div{}, true
+	case opcode.Mod:
+		return mod{}, true
+	case opcode.LT:
+		return lt{}, true
+	case opcode.GT:
+		return gt{}, true
+	case opcode.EQ:
+		return eq{}, true
+	case opcode.NOT:
+		return not{}, true
+	case opcode.Pop:
+		return pop{}, true
+	case opcode.Push:
+		return push{}, true
+	case opcode.Mload:
+		return mload{}, true
+	case opcode.Mstore:
+		return mstore{}, true
+	case opcode.Jump:
+		return jump{}, true
+	case opcode.Jumpi:
+		return jumpi{}, true
+	case opcode.Jumpdest:
+		return jumpdest{}, true
+	case opcode.Sdiv:
+		return sdiv{}, true
+	case opcode.Smod:
+		return smod{}, true
+	case opcode.Slt:
+		return slt{}, true
+	case opcode.Sgt:
+		return sgt{}, true
+	case opcode.Iszero:
+		return iszero{}, true
+	case opcode.And:
+		return and{}, true
+	case opcode.Or:
+		return or{}, true
+	case opcode.Xor:
+		return xor{}, true
+	case opcode.Shl:
+		return shl{}, true
+	case opcode.Shr:
+		return shr{}, true
+	case opcode.Sar:
+		return sar{}, true
+	case opcode.Call:
+		return call{}, true
+	case opcode.Return:
+		return ret{}, true
+	case opcode.Revert:
+		return revert{}, true
+	default:
+		return nil, false
+	}
+}
+
+// disassemble decodes rawByteCode into a linear sequence of opCodes and their
+// operand Data, and precomputes the set of valid JUMPDEST positions.
+func disassemble(rawByteCode []byte) (*assembly, error) {
+	code := make([]hexer, 0, len(rawByteCode))
+	jumpdests := make(map[int]bool)
+
+	for i := 0; i < len(rawByteCode); i++ {
+		op, ok := opCodeByHex(rawByteCode[i])
+		if !ok {
+			return nil, ErrInvalidOpcode
+		}
+
+		if _, ok := op.(jumpdest); ok {
+			jumpdests[len(code)] = true
+		}
+		code = append(code, op)
+
+		if _, ok := op.(push); ok {
+			// A push operand is length-prefixed (PUSH1..PUSH32 style): the
+			// byte right after the opcode gives the operand width in bytes.
+			if i+1 >= len(rawByteCode) {
+				return nil, ErrInvalidData
+			}
+
+			n := int(rawByteCode[i+1])
+			if n < 1 || n > wordBytes || i+1+n >= len(rawByteCode) {
+				return nil, ErrInvalidData
+			}
+
+			code = append(code, Data{raw: rawByteCode[i+2 : i+2+n]})
+			i += 1 + n
+		}
+	}
+
+	return &assembly{code: code, jumpdests: jumpdests}, nil
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright 2018 De-labtory
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vm
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/DE-labtory/koa/opcode"
+)
+
+func TestDisassembleRecordsJumpdests(t *testing.T) {
+	raw := []byte{byte(opcode.Jumpdest), byte(opcode.Add)}
+
+	asm, err := disassemble(raw)
+	if err != nil {
+		t.Fatalf("disassemble returned error: %v", err)
+	}
+
+	if !asm.jumpdests[0] {
+		t.Fatalf("jumpdests[0] = false, want true")
+	}
+	if asm.jumpdests[1] {
+		t.Fatalf("jumpdests[1] = true, want false (not a jumpdest)")
+	}
+}
+
+func TestDisassemblePushOperand(t *testing.T) {
+	raw := []byte{byte(opcode.Push), 2, 0xAB, 0xCD}
+
+	asm, err := disassemble(raw)
+	if err != nil {
+		t.Fatalf("disassemble returned error: %v", err)
+	}
+	if len(asm.code) != 2 {
+		t.Fatalf("len(code) = %d, want 2 (push + its Data)", len(asm.code))
+	}
+
+	data, ok := asm.code[1].(Data)
+	if !ok {
+		t.Fatalf("code[1] = %T, want Data", asm.code[1])
+	}
+	if !bytes.Equal(data.hex(), []byte{0xAB, 0xCD}) {
+		t.Fatalf("push operand = %x, want abcd", data.hex())
+	}
+}
+
+func TestDisassembleRejectsInvalidPushLength(t *testing.T) {
+	// length byte of 0 is not a usable operand width.
+	if _, err := disassemble([]byte{byte(opcode.Push), 0}); err != ErrInvalidData {
+		t.Fatalf("push with zero length err = %v, want ErrInvalidData", err)
+	}
+}
+
+func TestDisassembleRejectsTruncatedPush(t *testing.T) {
+	// claims a 3-byte operand but only 1 byte follows.
+	if _, err := disassemble([]byte{byte(opcode.Push), 3, 0x01}); err != ErrInvalidData {
+		t.Fatalf("truncated push err = %v, want ErrInvalidData", err)
+	}
+}
+
+func TestAssemblySeekToJumpdest(t *testing.T) {
+	asm, err := disassemble([]byte{byte(opcode.Jumpdest), byte(opcode.Add)})
+	if err != nil {
+		t.Fatalf("disassemble returned error: %v", err)
+	}
+
+	if err := asm.Seek(0); err != nil {
+		t.Fatalf("Seek(0) returned error: %v", err)
+	}
+	if got := asm.Pos(); got != 0 {
+		t.Fatalf("Pos() after Seek(0) = %d, want 0", got)
+	}
+}
+
+func TestAssemblySeekToNonJumpdest(t *testing.T) {
+	asm, err := disassemble([]byte{byte(opcode.Jumpdest), byte(opcode.Add)})
+	if err != nil {
+		t.Fatalf("disassemble returned error: %v", err)
+	}
+
+	if err := asm.Seek(1); err != ErrInvalidJumpDest {
+		t.Fatalf("Seek(1) err = %v, want ErrInvalidJumpDest", err)
+	}
+}
+
+func TestAssemblySeekOutOfRange(t *testing.T) {
+	asm, err := disassemble([]byte{byte(opcode.Jumpdest)})
+	if err != nil {
+		t.Fatalf("disassemble returned error: %v", err)
+	}
+
+	if err := asm.Seek(99); err != ErrInvalidJumpDest {
+		t.Fatalf("Seek(99) err = %v, want ErrInvalidJumpDest", err)
+	}
+}
+
+// TestExecuteJumpOverPush is a regression test for Execute losing track of
+// the cursor when a jump lands right after a push: push also advances the
+// cursor to consume its own operand, and Execute must not mistake that for
+// the jump itself.
+func TestExecuteJumpOverPush(t *testing.T) {
+	raw := []byte{
+		byte(opcode.Push), 1, 4, // push 4 (target: the jumpdest below)
+		byte(opcode.Jump),
+		byte(opcode.Add),      // skipped
+		byte(opcode.Jumpdest), // landing pad
+		byte(opcode.Push), 1, 3,
+		byte(opcode.Push), 1, 4,
+		byte(opcode.Add),
+	}
+
+	s, _, _, err := Execute(raw, NewMemory(), nil, NewGas(1000), nil)
+	if err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if got := s.pop(); got.toInt() != 7 {
+		t.Fatalf("result = %d, want 7", got.toInt())
+	}
+}
+
+func TestExecuteJumpToInvalidDest(t *testing.T) {
+	raw := []byte{
+		byte(opcode.Push), 1, 99, // not a valid jumpdest
+		byte(opcode.Jump),
+	}
+
+	_, _, _, err := Execute(raw, NewMemory(), nil, NewGas(1000), nil)
+	if err != ErrInvalidJumpDest {
+		t.Fatalf("Execute err = %v, want ErrInvalidJumpDest", err)
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright 2018 De-labtory
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestAndOrXor(t *testing.T) {
+	cases := []struct {
+		name string
+		op   opCode
+		x, y int64
+		want int64
+	}{
+		{"and", and{}, 0xF0, 0x1F, 0x10},
+		{"or", or{}, 0xF0, 0x0F, 0xFF},
+		{"xor", xor{}, 0xFF, 0x0F, 0xF0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := newStack()
+			s.push(itemFromInt64(c.x))
+			s.push(itemFromInt64(c.y))
+
+			if err := c.op.Do(s, nil, nil, nil); err != nil {
+				t.Fatalf("%s.Do returned error: %v", c.name, err)
+			}
+			if got := s.pop(); got.toInt() != int(c.want) {
+				t.Fatalf("%d %s %d = %d, want %d", c.x, c.name, c.y, got.toInt(), c.want)
+			}
+		})
+	}
+}
+
+func TestSltNegativeOperand(t *testing.T) {
+	s := newStack()
+	s.push(fromSigned(big.NewInt(-1))) // x
+	s.push(itemFromInt64(1))           // y
+
+	if err := (slt{}).Do(s, nil, nil, nil); err != nil {
+		t.Fatalf("slt.Do returned error: %v", err)
+	}
+	if got := s.pop(); got.toInt() != 1 {
+		t.Fatalf("-1 slt 1 = %d, want 1 (true)", got.toInt())
+	}
+}
+
+func TestSltDisagreesWithUnsignedLT(t *testing.T) {
+	// Unsigned, -1's bit pattern (2^256-1) is the largest possible value, so
+	// an unsigned LT says false; slt must say true by reading it as -1.
+	x, y := fromSigned(big.NewInt(-1)), itemFromInt64(1)
+
+	s := newStack()
+	s.push(x)
+	s.push(y)
+	if err := (lt{}).Do(s, nil, nil, nil); err != nil {
+		t.Fatalf("lt.Do returned error: %v", err)
+	}
+	if got := s.pop(); got.toInt() != 0 {
+		t.Fatalf("(2^256-1) lt 1 = %d, want 0 (false, unsigned)", got.toInt())
+	}
+
+	s.push(x)
+	s.push(y)
+	if err := (slt{}).Do(s, nil, nil, nil); err != nil {
+		t.Fatalf("slt.Do returned error: %v", err)
+	}
+	if got := s.pop(); got.toInt() != 1 {
+		t.Fatalf("-1 slt 1 = %d, want 1 (true, signed)", got.toInt())
+	}
+}
+
+func TestSgtNegativeOperand(t *testing.T) {
+	s := newStack()
+	s.push(itemFromInt64(1))           // x
+	s.push(fromSigned(big.NewInt(-1))) // y
+
+	if err := (sgt{}).Do(s, nil, nil, nil); err != nil {
+		t.Fatalf("sgt.Do returned error: %v", err)
+	}
+	if got := s.pop(); got.toInt() != 1 {
+		t.Fatalf("1 sgt -1 = %d, want 1 (true)", got.toInt())
+	}
+}
+
+func TestIszero(t *testing.T) {
+	s := newStack()
+	s.push(itemFromInt64(0))
+	if err := (iszero{}).Do(s, nil, nil, nil); err != nil {
+		t.Fatalf("iszero.Do returned error: %v", err)
+	}
+	if got := s.pop(); got.toInt() != 1 {
+		t.Fatalf("iszero(0) = %d, want 1", got.toInt())
+	}
+
+	s.push(itemFromInt64(5))
+	if err := (iszero{}).Do(s, nil, nil, nil); err != nil {
+		t.Fatalf("iszero.Do returned error: %v", err)
+	}
+	if got := s.pop(); got.toInt() != 0 {
+		t.Fatalf("iszero(5) = %d, want 0", got.toInt())
+	}
+}
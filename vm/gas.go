@@ -0,0 +1,147 @@
+/*
+ * Copyright 2018 De-labtory
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vm
+
+import (
+	"errors"
+
+	"github.com/DE-labtory/koa/opcode"
+)
+
+// ErrOutOfGas is returned by Execute when a contract exhausts its gas budget.
+var ErrOutOfGas = errors.New("out of gas")
+
+// GasTable gives the static gas cost of every opcode. Any opcode that can
+// grow Memory (mload, mstore, call, return, revert) also incurs a dynamic
+// cost proportional to how far it grows it; see gasCost.
+var GasTable = map[opcode.Type]uint64{
+	opcode.Add:      3,
+	opcode.Mul:      5,
+	opcode.Sub:      3,
+	opcode.Div:      5,
+	opcode.Mod:      5,
+	opcode.LT:       3,
+	opcode.GT:       3,
+	opcode.EQ:       3,
+	opcode.NOT:      3,
+	opcode.Pop:      2,
+	opcode.Push:     3,
+	opcode.Mload:    3,
+	opcode.Mstore:   3,
+	opcode.Jump:     8,
+	opcode.Jumpi:    10,
+	opcode.Jumpdest: 1,
+	opcode.Sdiv:     5,
+	opcode.Smod:     5,
+	opcode.Slt:      3,
+	opcode.Sgt:      3,
+	opcode.Iszero:   3,
+	opcode.And:      3,
+	opcode.Or:       3,
+	opcode.Xor:      3,
+	opcode.Shl:      3,
+	opcode.Shr:      3,
+	opcode.Sar:      3,
+	opcode.Call:     40,
+	opcode.Return:   0,
+	opcode.Revert:   0,
+}
+
+// Gas tracks the remaining gas budget for a single Execute call.
+type Gas struct {
+	remaining uint64
+}
+
+// NewGas returns a Gas budget starting at limit.
+func NewGas(limit uint64) *Gas {
+	return &Gas{remaining: limit}
+}
+
+// Remaining returns the gas left in the budget.
+func (g *Gas) Remaining() uint64 {
+	return g.remaining
+}
+
+// charge deducts cost from the budget, returning ErrOutOfGas without going negative.
+func (g *Gas) charge(cost uint64) error {
+	if cost > g.remaining {
+		g.remaining = 0
+		return ErrOutOfGas
+	}
+
+	g.remaining -= cost
+	return nil
+}
+
+// gasCost returns the total cost of executing op against the current stack
+// and memory, including the dynamic memory-expansion surcharge that every
+// opcode touching Memory incurs. It reads the stack's operands without
+// popping them, so it must agree with how op.Do itself reads them.
+func gasCost(op opCode, s *stack, mem *Memory) uint64 {
+	cost := GasTable[opcode.Type(op.hex()[0])]
+
+	switch op.(type) {
+	case mload:
+		cost += memoryExpansionGas(mem, s.peek(0).toInt(), wordBytes)
+	case mstore:
+		cost += memoryExpansionGas(mem, s.peek(0).toInt(), wordBytes)
+	case call:
+		// call pops (selector, argsOffset, argsSize, retOffset, retSize); it
+		// touches both the args region and the ret region.
+		argsOffset, argsSize := s.peek(1).toInt(), s.peek(2).toInt()
+		retOffset, retSize := s.peek(3).toInt(), s.peek(4).toInt()
+		cost += memoryExpansionGasMulti(mem, [2]int{argsOffset, argsSize}, [2]int{retOffset, retSize})
+	case ret, revert:
+		// ret/revert pop (offset, size) and copy that region into ReturnData.
+		cost += memoryExpansionGas(mem, s.peek(0).toInt(), s.peek(1).toInt())
+	}
+
+	return cost
+}
+
+// memoryExpansionGas prices growing Memory to cover [offset, offset+size),
+// quadratic in the number of words, as in the EVM memory gas schedule.
+func memoryExpansionGas(mem *Memory, offset, size int) uint64 {
+	return memoryExpansionGasMulti(mem, [2]int{offset, size})
+}
+
+// memoryExpansionGasMulti prices applying each [offset, offset+size) access
+// to mem in order, accounting for the fact that an earlier access in the
+// same opcode can already grow Memory before a later access is priced.
+func memoryExpansionGasMulti(mem *Memory, accesses ...[2]int) uint64 {
+	size := mem.Size()
+	var total uint64
+
+	for _, access := range accesses {
+		offset, n := access[0], access[1]
+		end := offset + n
+		if end <= size {
+			continue
+		}
+
+		oldWords := uint64(size / wordBytes)
+		newWords := uint64((end + wordBytes - 1) / wordBytes)
+		total += memoryWordsGas(newWords) - memoryWordsGas(oldWords)
+		size = int(newWords) * wordBytes
+	}
+
+	return total
+}
+
+func memoryWordsGas(words uint64) uint64 {
+	return 3*words + (words*words)/512
+}
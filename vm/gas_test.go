@@ -0,0 +1,117 @@
+/*
+ * Copyright 2018 De-labtory
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vm
+
+import (
+	"testing"
+
+	"github.com/DE-labtory/koa/opcode"
+)
+
+func TestGasChargeDeducts(t *testing.T) {
+	g := NewGas(10)
+
+	if err := g.charge(4); err != nil {
+		t.Fatalf("charge(4) returned error: %v", err)
+	}
+	if got := g.Remaining(); got != 6 {
+		t.Fatalf("Remaining() = %d, want 6", got)
+	}
+}
+
+func TestGasChargeExhaustion(t *testing.T) {
+	g := NewGas(5)
+
+	if err := g.charge(6); err != ErrOutOfGas {
+		t.Fatalf("charge(6) err = %v, want ErrOutOfGas", err)
+	}
+	if got := g.Remaining(); got != 0 {
+		t.Fatalf("Remaining() after out-of-gas = %d, want 0", got)
+	}
+}
+
+func TestMemoryExpansionGasNoChargeWithinBounds(t *testing.T) {
+	mem := NewMemory()
+	if err := mem.Store(0, make([]byte, wordBytes)); err != nil {
+		t.Fatalf("Store returned error: %v", err)
+	}
+
+	if got := memoryExpansionGas(mem, 0, wordBytes); got != 0 {
+		t.Fatalf("memoryExpansionGas within existing bounds = %d, want 0", got)
+	}
+}
+
+func TestMemoryExpansionGasChargesForGrowth(t *testing.T) {
+	mem := NewMemory()
+
+	got := memoryExpansionGas(mem, 0, wordBytes)
+	want := memoryWordsGas(1)
+	if got != want {
+		t.Fatalf("memoryExpansionGas(empty, 0, wordBytes) = %d, want %d", got, want)
+	}
+}
+
+// Two accesses within one opcode must be priced cumulatively: the second
+// access only pays for the growth the first one hasn't already covered.
+func TestMemoryExpansionGasMultiIsCumulative(t *testing.T) {
+	sequential := NewMemory()
+	firstCost := memoryExpansionGas(sequential, 0, wordBytes)
+	if err := sequential.Store(0, make([]byte, wordBytes)); err != nil {
+		t.Fatalf("Store returned error: %v", err)
+	}
+	secondCost := memoryExpansionGas(sequential, wordBytes, wordBytes)
+	want := firstCost + secondCost
+
+	fresh := NewMemory()
+	got := memoryExpansionGasMulti(fresh, [2]int{0, wordBytes}, [2]int{wordBytes, wordBytes})
+
+	if got != want {
+		t.Fatalf("memoryExpansionGasMulti = %d, want %d (sequential cost)", got, want)
+	}
+}
+
+func TestGasCostChargesMemoryExpansionForCall(t *testing.T) {
+	s := newStack()
+	// call pops (selector, argsOffset, argsSize, retOffset, retSize); push in
+	// reverse so selector ends up on top.
+	s.push(itemFromInt64(32)) // retSize
+	s.push(itemFromInt64(32)) // retOffset
+	s.push(itemFromInt64(32)) // argsSize
+	s.push(itemFromInt64(0))  // argsOffset
+	s.push(itemFromInt64(0))  // selector
+
+	mem := NewMemory()
+	want := GasTable[opcode.Call] + memoryExpansionGasMulti(NewMemory(), [2]int{0, 32}, [2]int{32, 32})
+
+	if got := gasCost(call{}, s, mem); got != want {
+		t.Fatalf("gasCost(call) = %d, want %d", got, want)
+	}
+}
+
+func TestGasCostChargesMemoryExpansionForRevert(t *testing.T) {
+	s := newStack()
+	// revert pops (offset, size).
+	s.push(itemFromInt64(32)) // size
+	s.push(itemFromInt64(0))  // offset
+
+	mem := NewMemory()
+	want := GasTable[opcode.Revert] + memoryExpansionGas(NewMemory(), 0, 32)
+
+	if got := gasCost(revert{}, s, mem); got != want {
+		t.Fatalf("gasCost(revert) = %d, want %d", got, want)
+	}
+}
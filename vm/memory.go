@@ -0,0 +1,92 @@
+/*
+ * Copyright 2018 De-labtory
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vm
+
+import "errors"
+
+// maxMemorySize bounds how large Memory is allowed to grow, guarding against
+// a single large offset turning into an out-of-memory allocation.
+const maxMemorySize = 1 << 24 // 16 MiB
+
+// ErrMemoryOverflow is returned when an offset/size is invalid, or when an
+// access would grow Memory past maxMemorySize.
+var ErrMemoryOverflow = errors.New("memory overflow")
+
+// Memory is a byte-addressed scratch space used by MLOAD/MSTORE. It grows
+// lazily: a write or read past the current end zero-fills up to the next
+// wordBytes-sized word boundary rather than requiring callers to pre-size it.
+type Memory struct {
+	store []byte
+}
+
+// NewMemory returns an empty Memory ready to grow on first access.
+func NewMemory() *Memory {
+	return &Memory{store: make([]byte, 0)}
+}
+
+// Size returns the current length of Memory in bytes, always a multiple of wordBytes.
+func (m *Memory) Size() int {
+	return len(m.store)
+}
+
+// Store writes value at offset, expanding Memory in word-sized chunks if needed.
+func (m *Memory) Store(offset int, value []byte) error {
+	if offset < 0 {
+		return ErrMemoryOverflow
+	}
+
+	if err := m.ensure(offset + len(value)); err != nil {
+		return err
+	}
+
+	copy(m.store[offset:], value)
+	return nil
+}
+
+// Load reads size bytes starting at offset, expanding Memory if the read
+// extends past its current end.
+func (m *Memory) Load(offset, size int) ([]byte, error) {
+	if offset < 0 || size < 0 {
+		return nil, ErrMemoryOverflow
+	}
+
+	if err := m.ensure(offset + size); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, size)
+	copy(data, m.store[offset:offset+size])
+	return data, nil
+}
+
+// ensure grows store, zero-filled, so it is at least end bytes long, rounded
+// up to the next word boundary.
+func (m *Memory) ensure(end int) error {
+	if end < 0 || end > maxMemorySize {
+		return ErrMemoryOverflow
+	}
+
+	if end <= len(m.store) {
+		return nil
+	}
+
+	words := (end + wordBytes - 1) / wordBytes
+	grown := make([]byte, words*wordBytes)
+	copy(grown, m.store)
+	m.store = grown
+	return nil
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2018 De-labtory
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMemoryStoreLoadRoundTrip(t *testing.T) {
+	m := NewMemory()
+	want := []byte{0x01, 0x02, 0x03, 0x04}
+
+	if err := m.Store(0, want); err != nil {
+		t.Fatalf("Store returned error: %v", err)
+	}
+
+	got, err := m.Load(0, len(want))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Load() = %x, want %x", got, want)
+	}
+}
+
+func TestMemoryGrowsInWordSizedChunks(t *testing.T) {
+	m := NewMemory()
+
+	if err := m.Store(1, []byte{0xFF}); err != nil {
+		t.Fatalf("Store returned error: %v", err)
+	}
+
+	if got := m.Size(); got != wordBytes {
+		t.Fatalf("Size() = %d, want %d (one word)", got, wordBytes)
+	}
+}
+
+func TestMemoryLoadPastEndZeroFills(t *testing.T) {
+	m := NewMemory()
+
+	got, err := m.Load(0, wordBytes)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if !bytes.Equal(got, make([]byte, wordBytes)) {
+		t.Fatalf("Load() on untouched memory = %x, want all zero", got)
+	}
+}
+
+func TestMemoryNegativeOffsetOverflows(t *testing.T) {
+	m := NewMemory()
+
+	if _, err := m.Load(-1, 1); err != ErrMemoryOverflow {
+		t.Fatalf("Load(-1, 1) err = %v, want ErrMemoryOverflow", err)
+	}
+	if err := m.Store(-1, []byte{0x00}); err != ErrMemoryOverflow {
+		t.Fatalf("Store(-1, ...) err = %v, want ErrMemoryOverflow", err)
+	}
+}
+
+func TestMemoryPastMaxSizeOverflows(t *testing.T) {
+	m := NewMemory()
+
+	if _, err := m.Load(0, maxMemorySize+1); err != ErrMemoryOverflow {
+		t.Fatalf("Load past maxMemorySize err = %v, want ErrMemoryOverflow", err)
+	}
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright 2018 De-labtory
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vm
+
+import (
+	"encoding/hex"
+	"math"
+	"math/big"
+)
+
+// wordBits/wordBytes are the width of a single stack item, matching the
+// 256-bit word size used by the EVM and sized to hold a hash, address or balance.
+const wordBits = 256
+const wordBytes = 32
+
+// wordMod is 2^256; every item is kept canonical in [0, wordMod) so it
+// behaves as an unsigned 256-bit word with wraparound on overflow.
+var wordMod = new(big.Int).Lsh(big.NewInt(1), wordBits)
+
+// wordMask is 2^256 - 1, used to implement bitwise NOT.
+var wordMask = new(big.Int).Sub(wordMod, big.NewInt(1))
+
+// item is a single 256-bit unsigned word on the VM's evaluation stack.
+type item struct {
+	val *big.Int
+}
+
+// newItem canonicalizes v into an item in [0, 2^256), wrapping on overflow/underflow.
+func newItem(v *big.Int) item {
+	return item{val: new(big.Int).Mod(v, wordMod)}
+}
+
+func itemFromInt64(v int64) item {
+	return newItem(big.NewInt(v))
+}
+
+// signed reinterprets the item's canonical bit pattern as a two's-complement
+// signed 256-bit integer, for use by the signed opcodes (sdiv, smod, slt, sgt, sar).
+func (it item) signed() *big.Int {
+	if it.val.Bit(wordBits-1) == 0 {
+		return new(big.Int).Set(it.val)
+	}
+
+	return new(big.Int).Sub(it.val, wordMod)
+}
+
+// fromSigned canonicalizes a signed result back into the item's unsigned wraparound form.
+func fromSigned(v *big.Int) item {
+	return newItem(v)
+}
+
+// shiftCount clamps a shift amount item to a usable uint, treating anything
+// that can't represent a shift smaller than the word width as a full shift-out.
+func (it item) shiftCount() uint {
+	if !it.val.IsUint64() {
+		return wordBits
+	}
+
+	n := it.val.Uint64()
+	if n > wordBits {
+		return wordBits
+	}
+
+	return uint(n)
+}
+
+func (it item) isZero() bool {
+	return it.val.Sign() == 0
+}
+
+func (it item) cmp(other item) int {
+	return it.val.Cmp(other.val)
+}
+
+// toInt converts an item to a plain int for use as a memory offset or jump
+// target, saturating to math.MaxInt32 if the value is too large to address.
+func (it item) toInt() int {
+	if !it.val.IsInt64() {
+		return math.MaxInt32
+	}
+
+	v := it.val.Int64()
+	if v < 0 || v > math.MaxInt32 {
+		return math.MaxInt32
+	}
+
+	return int(v)
+}
+
+// bytesToWord decodes a big-endian byte slice into an item.
+func bytesToWord(b []byte) item {
+	return newItem(new(big.Int).SetBytes(b))
+}
+
+// wordToBytes encodes an item as a big-endian, wordBytes-wide byte slice.
+func wordToBytes(it item) []byte {
+	b := it.val.Bytes()
+	buf := make([]byte, wordBytes)
+	copy(buf[wordBytes-len(b):], b)
+	return buf
+}
+
+// stack is the VM's evaluation stack.
+type stack struct {
+	items []item
+}
+
+func newStack() *stack {
+	return &stack{items: make([]item, 0)}
+}
+
+func (s *stack) push(i item) {
+	s.items = append(s.items, i)
+}
+
+// pop removes and returns the top of the stack, or the zero item if empty.
+func (s *stack) pop() item {
+	if len(s.items) == 0 {
+		return itemFromInt64(0)
+	}
+
+	i := s.items[len(s.items)-1]
+	s.items = s.items[:len(s.items)-1]
+	return i
+}
+
+func (s *stack) size() int {
+	return len(s.items)
+}
+
+// snapshot returns the stack's items, bottom to top, as a hex-encoded
+// StackView, for use by a Tracer.
+func (s *stack) snapshot() StackView {
+	out := make(StackView, len(s.items))
+	for i, it := range s.items {
+		out[i] = hex.EncodeToString(wordToBytes(it))
+	}
+
+	return out
+}
+
+// peek returns the nth item from the top of the stack (0 is the top)
+// without removing it, or the zero item if the stack is too shallow.
+func (s *stack) peek(n int) item {
+	i := len(s.items) - 1 - n
+	if i < 0 {
+		return itemFromInt64(0)
+	}
+
+	return s.items[i]
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright 2018 De-labtory
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestNewItemWrapsNegative(t *testing.T) {
+	it := newItem(big.NewInt(-1))
+	want := new(big.Int).Sub(wordMod, big.NewInt(1))
+
+	if it.val.Cmp(want) != 0 {
+		t.Fatalf("newItem(-1) = %s, want %s", it.val, want)
+	}
+}
+
+func TestNewItemWrapsOverflow(t *testing.T) {
+	it := newItem(wordMod) // exactly 2^256 wraps to 0
+	if !it.isZero() {
+		t.Fatalf("newItem(2^256) = %s, want 0", it.val)
+	}
+}
+
+func TestItemSignedRoundTrip(t *testing.T) {
+	neg := fromSigned(big.NewInt(-5))
+	if got := neg.signed(); got.Cmp(big.NewInt(-5)) != 0 {
+		t.Fatalf("fromSigned(-5).signed() = %s, want -5", got)
+	}
+
+	pos := fromSigned(big.NewInt(5))
+	if got := pos.signed(); got.Cmp(big.NewInt(5)) != 0 {
+		t.Fatalf("fromSigned(5).signed() = %s, want 5", got)
+	}
+}
+
+func TestShiftCountClampsAtWordWidth(t *testing.T) {
+	if got := itemFromInt64(300).shiftCount(); got != wordBits {
+		t.Fatalf("shiftCount(300) = %d, want %d", got, wordBits)
+	}
+	if got := itemFromInt64(10).shiftCount(); got != 10 {
+		t.Fatalf("shiftCount(10) = %d, want 10", got)
+	}
+}
+
+func TestShiftCountClampsHugeValue(t *testing.T) {
+	// The largest representable item doesn't fit in a uint64, so it must
+	// clamp to a full shift-out rather than wrapping back to a small count.
+	huge := newItem(wordMask)
+	if got := huge.shiftCount(); got != wordBits {
+		t.Fatalf("shiftCount(2^256-1) = %d, want %d", got, wordBits)
+	}
+}
+
+func TestBytesWordRoundTrip(t *testing.T) {
+	raw := make([]byte, wordBytes)
+	raw[wordBytes-1] = 0x2A
+	raw[0] = 0x01
+
+	got := wordToBytes(bytesToWord(raw))
+	if !bytes.Equal(got, raw) {
+		t.Fatalf("round trip = %x, want %x", got, raw)
+	}
+}
+
+func TestStackPushPopOrder(t *testing.T) {
+	s := newStack()
+	s.push(itemFromInt64(1))
+	s.push(itemFromInt64(2))
+
+	if got := s.pop(); got.cmp(itemFromInt64(2)) != 0 {
+		t.Fatalf("first pop = %s, want 2", got.val)
+	}
+	if got := s.pop(); got.cmp(itemFromInt64(1)) != 0 {
+		t.Fatalf("second pop = %s, want 1", got.val)
+	}
+}
+
+func TestStackPopEmptyYieldsZero(t *testing.T) {
+	s := newStack()
+	if got := s.pop(); !got.isZero() {
+		t.Fatalf("pop on empty stack = %s, want 0", got.val)
+	}
+}
+
+func TestAddWrapsAroundWordMod(t *testing.T) {
+	s := newStack()
+	s.push(newItem(wordMask)) // 2^256 - 1
+	s.push(itemFromInt64(2))
+
+	if err := (add{}).Do(s, nil, nil, nil); err != nil {
+		t.Fatalf("add.Do returned error: %v", err)
+	}
+	if got := s.pop(); got.cmp(itemFromInt64(1)) != 0 {
+		t.Fatalf("(2^256-1)+2 = %s, want 1", got.val)
+	}
+}
+
+func TestDivByZeroPushesZero(t *testing.T) {
+	s := newStack()
+	s.push(itemFromInt64(5))
+	s.push(itemFromInt64(0))
+
+	if err := (div{}).Do(s, nil, nil, nil); err != nil {
+		t.Fatalf("div.Do returned error: %v", err)
+	}
+	if got := s.pop(); !got.isZero() {
+		t.Fatalf("5 / 0 = %s, want 0", got.val)
+	}
+}
+
+func TestModByZeroPushesZero(t *testing.T) {
+	s := newStack()
+	s.push(itemFromInt64(5))
+	s.push(itemFromInt64(0))
+
+	if err := (mod{}).Do(s, nil, nil, nil); err != nil {
+		t.Fatalf("mod.Do returned error: %v", err)
+	}
+	if got := s.pop(); !got.isZero() {
+		t.Fatalf("5 %% 0 = %s, want 0", got.val)
+	}
+}
+
+func TestSignedDivModTruncatesTowardZero(t *testing.T) {
+	// -7 / 2 truncates to -3 with remainder -1, matching Go's native %
+	// rather than Euclidean division (which would give -4 and 1).
+	q, r := signedDivMod(fromSigned(big.NewInt(-7)), fromSigned(big.NewInt(2)))
+
+	if got := q.signed(); got.Cmp(big.NewInt(-3)) != 0 {
+		t.Fatalf("-7 / 2 = %s, want -3", got)
+	}
+	if got := r.signed(); got.Cmp(big.NewInt(-1)) != 0 {
+		t.Fatalf("-7 %% 2 = %s, want -1", got)
+	}
+}
+
+func TestSignedDivModByZero(t *testing.T) {
+	q, r := signedDivMod(itemFromInt64(5), itemFromInt64(0))
+
+	if !q.isZero() || !r.isZero() {
+		t.Fatalf("signedDivMod(5, 0) = (%s, %s), want (0, 0)", q.val, r.val)
+	}
+}
+
+func TestShlShiftsOutPastWordWidth(t *testing.T) {
+	s := newStack()
+	s.push(itemFromInt64(1))
+	s.push(itemFromInt64(300)) // shift count clamps to wordBits
+
+	if err := (shl{}).Do(s, nil, nil, nil); err != nil {
+		t.Fatalf("shl.Do returned error: %v", err)
+	}
+	if got := s.pop(); !got.isZero() {
+		t.Fatalf("1 << 300 = %s, want 0", got.val)
+	}
+}
+
+func TestSarSignExtends(t *testing.T) {
+	s := newStack()
+	s.push(fromSigned(big.NewInt(-8)))
+	s.push(itemFromInt64(1))
+
+	if err := (sar{}).Do(s, nil, nil, nil); err != nil {
+		t.Fatalf("sar.Do returned error: %v", err)
+	}
+	if got := s.pop().signed(); got.Cmp(big.NewInt(-4)) != 0 {
+		t.Fatalf("-8 >> 1 (arithmetic) = %s, want -4", got)
+	}
+}
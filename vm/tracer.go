@@ -0,0 +1,164 @@
+/*
+ * Copyright 2018 De-labtory
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/DE-labtory/koa/opcode"
+)
+
+// StackView is a read-only, hex-encoded snapshot of the evaluation stack,
+// bottom to top, handed to a Tracer. It exists because the real stack type
+// is unexported: a Tracer implemented outside package vm can't take it directly.
+type StackView []string
+
+// Tracer lets a caller observe Execute step by step, e.g. to debug a koa
+// contract or diff the same run across VM versions. Implementations may
+// live in any package.
+type Tracer interface {
+	// CaptureState is called immediately before and immediately after an
+	// opCode runs. err is nil on the before-call, and holds whatever the
+	// opCode returned on the after-call. If the opCode's gas cannot be paid,
+	// the after-call carries ErrOutOfGas and the opCode does not run.
+	CaptureState(pc int, op opcode.Type, stack StackView, mem *Memory, gas uint64, err error)
+	// CaptureEnd is called once, when Execute returns.
+	CaptureEnd(output []byte, gasUsed uint64, err error)
+}
+
+// ExecOptions carries optional Execute behavior that doesn't belong on every call site.
+type ExecOptions struct {
+	Tracer Tracer
+}
+
+var opcodeNames = map[opcode.Type]string{
+	opcode.Add:      "ADD",
+	opcode.Mul:      "MUL",
+	opcode.Sub:      "SUB",
+	opcode.Div:      "DIV",
+	opcode.Mod:      "MOD",
+	opcode.LT:       "LT",
+	opcode.GT:       "GT",
+	opcode.EQ:       "EQ",
+	opcode.NOT:      "NOT",
+	opcode.Pop:      "POP",
+	opcode.Push:     "PUSH",
+	opcode.Mload:    "MLOAD",
+	opcode.Mstore:   "MSTORE",
+	opcode.Jump:     "JUMP",
+	opcode.Jumpi:    "JUMPI",
+	opcode.Jumpdest: "JUMPDEST",
+	opcode.Sdiv:     "SDIV",
+	opcode.Smod:     "SMOD",
+	opcode.Slt:      "SLT",
+	opcode.Sgt:      "SGT",
+	opcode.Iszero:   "ISZERO",
+	opcode.And:      "AND",
+	opcode.Or:       "OR",
+	opcode.Xor:      "XOR",
+	opcode.Shl:      "SHL",
+	opcode.Shr:      "SHR",
+	opcode.Sar:      "SAR",
+	opcode.Call:     "CALL",
+	opcode.Return:   "RETURN",
+	opcode.Revert:   "REVERT",
+}
+
+func opcodeName(op opcode.Type) string {
+	if name, ok := opcodeNames[op]; ok {
+		return name
+	}
+
+	return "UNKNOWN"
+}
+
+// JSONLogger is a built-in Tracer that writes one JSON object to out per
+// CaptureState call (so two per executed opCode, before and after) and one
+// more for CaptureEnd.
+type JSONLogger struct {
+	out io.Writer
+}
+
+// NewJSONLogger returns a JSONLogger that writes its trace to out.
+func NewJSONLogger(out io.Writer) *JSONLogger {
+	return &JSONLogger{out: out}
+}
+
+type jsonLogStep struct {
+	Pc      int      `json:"pc"`
+	Op      string   `json:"op"`
+	Stack   []string `json:"stack"`
+	MemHash string   `json:"memHash"`
+	Gas     uint64   `json:"gas"`
+	Err     string   `json:"err,omitempty"`
+}
+
+// CaptureState writes a single step as one JSON object.
+func (l *JSONLogger) CaptureState(pc int, op opcode.Type, stack StackView, mem *Memory, gas uint64, err error) {
+	step := jsonLogStep{
+		Pc:      pc,
+		Op:      opcodeName(op),
+		Stack:   stack,
+		MemHash: memHash(mem),
+		Gas:     gas,
+	}
+	if err != nil {
+		step.Err = err.Error()
+	}
+
+	data, err := json.Marshal(step)
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintln(l.out, string(data))
+}
+
+type jsonLogEnd struct {
+	Output  string `json:"output"`
+	GasUsed uint64 `json:"gasUsed"`
+	Err     string `json:"err,omitempty"`
+}
+
+// CaptureEnd writes the run's final outcome as one JSON object.
+func (l *JSONLogger) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	end := jsonLogEnd{
+		Output:  hex.EncodeToString(output),
+		GasUsed: gasUsed,
+	}
+	if err != nil {
+		end.Err = err.Error()
+	}
+
+	data, mErr := json.Marshal(end)
+	if mErr != nil {
+		return
+	}
+
+	fmt.Fprintln(l.out, string(data))
+}
+
+// memHash summarizes Memory's contents so a trace can show when/whether memory changed
+// without dumping its full, potentially large, contents.
+func memHash(mem *Memory) string {
+	sum := sha256.Sum256(mem.store)
+	return hex.EncodeToString(sum[:])
+}
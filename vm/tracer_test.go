@@ -0,0 +1,128 @@
+/*
+ * Copyright 2018 De-labtory
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vm
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/DE-labtory/koa/opcode"
+)
+
+// fakeTracer records every CaptureState/CaptureEnd call it receives, so a
+// test can assert on how many times and in what order Execute calls them.
+type fakeTracer struct {
+	states   []opcode.Type
+	endCalls int
+	gasUsed  uint64
+	endErr   error
+}
+
+func (f *fakeTracer) CaptureState(pc int, op opcode.Type, stack StackView, mem *Memory, gas uint64, err error) {
+	f.states = append(f.states, op)
+}
+
+func (f *fakeTracer) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	f.endCalls++
+	f.gasUsed = gasUsed
+	f.endErr = err
+}
+
+func TestExecuteTracesBeforeAndAfterEachOp(t *testing.T) {
+	raw := []byte{
+		byte(opcode.Push), 1, 5,
+		byte(opcode.Push), 1, 3,
+		byte(opcode.Add),
+	}
+
+	tracer := &fakeTracer{}
+	gas := NewGas(1000)
+	_, _, resultGas, err := Execute(raw, NewMemory(), nil, gas, &ExecOptions{Tracer: tracer})
+	if err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+
+	// 3 opCodes (push, push, add) each traced once before and once after.
+	wantOps := []opcode.Type{
+		opcode.Push, opcode.Push,
+		opcode.Push, opcode.Push,
+		opcode.Add, opcode.Add,
+	}
+	if len(tracer.states) != len(wantOps) {
+		t.Fatalf("CaptureState called %d times, want %d", len(tracer.states), len(wantOps))
+	}
+	for i, op := range wantOps {
+		if tracer.states[i] != op {
+			t.Fatalf("states[%d] = %v, want %v", i, tracer.states[i], op)
+		}
+	}
+
+	if tracer.endCalls != 1 {
+		t.Fatalf("CaptureEnd called %d times, want 1", tracer.endCalls)
+	}
+	if tracer.endErr != nil {
+		t.Fatalf("CaptureEnd err = %v, want nil", tracer.endErr)
+	}
+	if want := 1000 - resultGas.Remaining(); tracer.gasUsed != want {
+		t.Fatalf("CaptureEnd gasUsed = %d, want %d", tracer.gasUsed, want)
+	}
+}
+
+func TestJSONLoggerEmitsOneObjectPerStepAndOneEnd(t *testing.T) {
+	raw := []byte{
+		byte(opcode.Push), 1, 5,
+		byte(opcode.Push), 1, 3,
+		byte(opcode.Add),
+	}
+
+	var buf bytes.Buffer
+	logger := NewJSONLogger(&buf)
+
+	if _, _, _, err := Execute(raw, NewMemory(), nil, NewGas(1000), &ExecOptions{Tracer: logger}); err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	// 3 opCodes * 2 (before/after) + 1 end line.
+	if len(lines) != 7 {
+		t.Fatalf("got %d logged lines, want 7", len(lines))
+	}
+
+	var step jsonLogStep
+	if err := json.Unmarshal([]byte(lines[0]), &step); err != nil {
+		t.Fatalf("unmarshal step line: %v", err)
+	}
+	if step.Op != "PUSH" {
+		t.Fatalf("first step Op = %q, want PUSH", step.Op)
+	}
+	if step.MemHash == "" {
+		t.Fatalf("step MemHash is empty")
+	}
+
+	var end jsonLogEnd
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &end); err != nil {
+		t.Fatalf("unmarshal end line: %v", err)
+	}
+	if end.Err != "" {
+		t.Fatalf("end.Err = %q, want empty", end.Err)
+	}
+	if end.GasUsed == 0 {
+		t.Fatalf("end.GasUsed = 0, want > 0")
+	}
+}
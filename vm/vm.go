@@ -17,43 +17,116 @@
 package vm
 
 import (
-	"encoding/binary"
 	"errors"
+	"math/big"
 
 	"github.com/DE-labtory/koa/opcode"
 )
 
 var ErrInvalidData = errors.New("Invalid data")
 var ErrInvalidOpcode = errors.New("invalid opcode")
+var ErrInvalidJumpDest = errors.New("invalid jump destination")
+var ErrReverted = errors.New("execution reverted")
+
+// errNormalHalt is an internal sentinel used by the return opCode to unwind
+// Execute's loop cleanly; it never escapes Execute as a reported error.
+var errNormalHalt = errors.New("normal halt")
 
 // The Execute function assemble the rawByteCode into an assembly code,
-// which in turn executes the assembly logic.
-func Execute(rawByteCode []byte, memory *Memory, callFunc *CallFunc) (*stack, error) {
+// which in turn executes the assembly logic. gas bounds how much work the
+// bytecode may do; Execute aborts with ErrOutOfGas the moment it's exhausted.
+// The returned []byte is the contract's return data, set by a return or
+// revert opCode. opts may be nil; if it carries a Tracer, Execute reports
+// every step to it.
+func Execute(rawByteCode []byte, memory *Memory, callFunc *CallFunc, gas *Gas, opts *ExecOptions) (resultStack *stack, output []byte, resultGas *Gas, resultErr error) {
+	startGas := gas.Remaining()
+	resultGas = gas
+
+	var tracer Tracer
+	if opts != nil {
+		tracer = opts.Tracer
+	}
+	if tracer != nil {
+		defer func() {
+			tracer.CaptureEnd(output, startGas-resultGas.Remaining(), resultErr)
+		}()
+	}
 
 	s := newStack()
+	resultStack = s
+
 	asm, err := disassemble(rawByteCode)
 	if err != nil {
-		return &stack{}, err
+		resultStack, resultErr = &stack{}, err
+		return
 	}
 
-	for h := asm.code[0]; h != nil; h = asm.next() {
+	for h := asm.cur(); h != nil; {
 		op, ok := h.(opCode)
 		if !ok {
-			return &stack{}, ErrInvalidOpcode
+			resultStack, resultErr = &stack{}, ErrInvalidOpcode
+			return
+		}
+
+		opType := opcode.Type(op.hex()[0])
+		if tracer != nil {
+			tracer.CaptureState(asm.Pos(), opType, s.snapshot(), memory, gas.Remaining(), nil)
+		}
+
+		if err := gas.charge(gasCost(op, s, memory)); err != nil {
+			if tracer != nil {
+				tracer.CaptureState(asm.Pos(), opType, s.snapshot(), memory, gas.Remaining(), err)
+			}
+			resultErr = err
+			return
+		}
+
+		pos := asm.Pos()
+		doErr := op.Do(s, asm, memory, callFunc)
+
+		if tracer != nil {
+			tracer.CaptureState(pos, opType, s.snapshot(), memory, gas.Remaining(), doErr)
+		}
+
+		if doErr == errNormalHalt {
+			output, resultErr = returnDataOf(callFunc), nil
+			return
+		}
+		if doErr != nil {
+			output, resultErr = returnDataOf(callFunc), doErr
+			return
 		}
 
-		err := op.Do(s, asm, memory, callFunc)
-		if err != nil {
-			return s, err
+		if asm.consumeJumped() {
+			h = asm.cur()
+		} else {
+			h = asm.next()
 		}
 	}
 
-	return s, nil
+	return
+}
+
+// returnDataOf safely reads the ReturnData a call/return/revert left behind,
+// tolerating a nil callFunc.
+func returnDataOf(callFunc *CallFunc) []byte {
+	if callFunc == nil {
+		return nil
+	}
+
+	return callFunc.ReturnData
 }
 
+// CallFunc carries both the inputs available to the executing contract
+// (Func, Args) and the outputs of a call/return/revert: Resolver dispatches
+// the `call` opCode to another contract, and ReturnData holds whatever the
+// contract passed to `return` or `revert`.
 type CallFunc struct {
 	Func []byte
 	Args []byte
+
+	Resolver   func(selector []byte, args []byte) ([]byte, error)
+	ReturnData []byte
 }
 
 type opCode interface {
@@ -75,12 +148,29 @@ type pop struct{}
 type push struct{}
 type mload struct{}
 type mstore struct{}
+type jump struct{}
+type jumpi struct{}
+type jumpdest struct{}
+type sdiv struct{}
+type smod struct{}
+type slt struct{}
+type sgt struct{}
+type iszero struct{}
+type and struct{}
+type or struct{}
+type xor struct{}
+type shl struct{}
+type shr struct{}
+type sar struct{}
+type call struct{}
+type ret struct{}
+type revert struct{}
 
 func (add) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
 	y := stack.pop()
 	x := stack.pop()
 
-	stack.push(x + y)
+	stack.push(newItem(new(big.Int).Add(x.val, y.val)))
 
 	return nil
 }
@@ -93,7 +183,7 @@ func (mul) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
 	y := stack.pop()
 	x := stack.pop()
 
-	stack.push(x * y)
+	stack.push(newItem(new(big.Int).Mul(x.val, y.val)))
 
 	return nil
 }
@@ -106,7 +196,7 @@ func (sub) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
 	y := stack.pop()
 	x := stack.pop()
 
-	stack.push(x - y)
+	stack.push(newItem(new(big.Int).Sub(x.val, y.val)))
 
 	return nil
 }
@@ -115,14 +205,17 @@ func (sub) hex() []uint8 {
 	return []uint8{uint8(opcode.Sub)}
 }
 
-// Be careful! int.Div and int.Quo is different
+// div is the unsigned division x / y.
 func (div) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
 	y := stack.pop()
 	x := stack.pop()
 
-	item, _ := euclidean_div(x, y)
+	if y.isZero() {
+		stack.push(itemFromInt64(0))
+		return nil
+	}
 
-	stack.push(item)
+	stack.push(newItem(new(big.Int).Quo(x.val, y.val)))
 
 	return nil
 }
@@ -131,13 +224,17 @@ func (div) hex() []uint8 {
 	return []uint8{uint8(opcode.Div)}
 }
 
+// mod is the unsigned remainder of x / y.
 func (mod) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
 	y := stack.pop()
 	x := stack.pop()
 
-	_, item := euclidean_div(x, y)
+	if y.isZero() {
+		stack.push(itemFromInt64(0))
+		return nil
+	}
 
-	stack.push(item)
+	stack.push(newItem(new(big.Int).Rem(x.val, y.val)))
 
 	return nil
 }
@@ -146,13 +243,14 @@ func (mod) hex() []uint8 {
 	return []uint8{uint8(opcode.Mod)}
 }
 
+// lt is the unsigned comparison x < y.
 func (lt) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
 	y, x := stack.pop(), stack.pop()
 
-	if x < y { // x < y
-		stack.push(item(1))
+	if x.cmp(y) < 0 {
+		stack.push(itemFromInt64(1))
 	} else {
-		stack.push(item(0))
+		stack.push(itemFromInt64(0))
 	}
 
 	return nil
@@ -162,13 +260,14 @@ func (lt) hex() []uint8 {
 	return []uint8{uint8(opcode.LT)}
 }
 
+// gt is the unsigned comparison x > y.
 func (gt) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
 	y, x := stack.pop(), stack.pop()
 
-	if x > y { // x > y
-		stack.push(item(1))
+	if x.cmp(y) > 0 {
+		stack.push(itemFromInt64(1))
 	} else {
-		stack.push(item(0))
+		stack.push(itemFromInt64(0))
 	}
 
 	return nil
@@ -178,13 +277,78 @@ func (gt) hex() []uint8 {
 	return []uint8{uint8(opcode.GT)}
 }
 
+// Be careful! int.Div and int.Quo is different
+func (sdiv) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
+	y := stack.pop()
+	x := stack.pop()
+
+	item, _ := signedDivMod(x, y)
+
+	stack.push(item)
+
+	return nil
+}
+
+func (sdiv) hex() []uint8 {
+	return []uint8{uint8(opcode.Sdiv)}
+}
+
+func (smod) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
+	y := stack.pop()
+	x := stack.pop()
+
+	_, item := signedDivMod(x, y)
+
+	stack.push(item)
+
+	return nil
+}
+
+func (smod) hex() []uint8 {
+	return []uint8{uint8(opcode.Smod)}
+}
+
+// slt is the signed comparison x < y, interpreting both operands as two's-complement.
+func (slt) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
+	y, x := stack.pop(), stack.pop()
+
+	if x.signed().Cmp(y.signed()) < 0 {
+		stack.push(itemFromInt64(1))
+	} else {
+		stack.push(itemFromInt64(0))
+	}
+
+	return nil
+}
+
+func (slt) hex() []uint8 {
+	return []uint8{uint8(opcode.Slt)}
+}
+
+// sgt is the signed comparison x > y, interpreting both operands as two's-complement.
+func (sgt) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
+	y, x := stack.pop(), stack.pop()
+
+	if x.signed().Cmp(y.signed()) > 0 {
+		stack.push(itemFromInt64(1))
+	} else {
+		stack.push(itemFromInt64(0))
+	}
+
+	return nil
+}
+
+func (sgt) hex() []uint8 {
+	return []uint8{uint8(opcode.Sgt)}
+}
+
 func (eq) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
 	y, x := stack.pop(), stack.pop()
 
-	if x == y { // x == y
-		stack.push(item(1))
+	if x.cmp(y) == 0 {
+		stack.push(itemFromInt64(1))
 	} else {
-		stack.push(item(0))
+		stack.push(itemFromInt64(0))
 	}
 
 	return nil
@@ -194,10 +358,11 @@ func (eq) hex() []uint8 {
 	return []uint8{uint8(opcode.EQ)}
 }
 
+// not is the bitwise complement of x.
 func (not) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
 	x := stack.pop()
 
-	stack.push(^x)
+	stack.push(newItem(new(big.Int).Xor(x.val, wordMask)))
 	return nil
 }
 
@@ -205,6 +370,104 @@ func (not) hex() []uint8 {
 	return []uint8{uint8(opcode.NOT)}
 }
 
+// iszero is the logical NOT of x: it pushes 1 if x is zero, 0 otherwise.
+func (iszero) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
+	x := stack.pop()
+
+	if x.isZero() {
+		stack.push(itemFromInt64(1))
+	} else {
+		stack.push(itemFromInt64(0))
+	}
+
+	return nil
+}
+
+func (iszero) hex() []uint8 {
+	return []uint8{uint8(opcode.Iszero)}
+}
+
+func (and) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
+	y := stack.pop()
+	x := stack.pop()
+
+	stack.push(newItem(new(big.Int).And(x.val, y.val)))
+
+	return nil
+}
+
+func (and) hex() []uint8 {
+	return []uint8{uint8(opcode.And)}
+}
+
+func (or) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
+	y := stack.pop()
+	x := stack.pop()
+
+	stack.push(newItem(new(big.Int).Or(x.val, y.val)))
+
+	return nil
+}
+
+func (or) hex() []uint8 {
+	return []uint8{uint8(opcode.Or)}
+}
+
+func (xor) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
+	y := stack.pop()
+	x := stack.pop()
+
+	stack.push(newItem(new(big.Int).Xor(x.val, y.val)))
+
+	return nil
+}
+
+func (xor) hex() []uint8 {
+	return []uint8{uint8(opcode.Xor)}
+}
+
+// shl is the logical left shift x << y.
+func (shl) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
+	y := stack.pop()
+	x := stack.pop()
+
+	stack.push(newItem(new(big.Int).Lsh(x.val, y.shiftCount())))
+
+	return nil
+}
+
+func (shl) hex() []uint8 {
+	return []uint8{uint8(opcode.Shl)}
+}
+
+// shr is the logical right shift: the shifted-in bits are always zero.
+func (shr) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
+	y := stack.pop()
+	x := stack.pop()
+
+	stack.push(newItem(new(big.Int).Rsh(x.val, y.shiftCount())))
+
+	return nil
+}
+
+func (shr) hex() []uint8 {
+	return []uint8{uint8(opcode.Shr)}
+}
+
+// sar is the arithmetic right shift x >> y, sign-extending the shifted-in bits.
+func (sar) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
+	y := stack.pop()
+	x := stack.pop()
+
+	stack.push(fromSigned(new(big.Int).Rsh(x.signed(), y.shiftCount())))
+
+	return nil
+}
+
+func (sar) hex() []uint8 {
+	return []uint8{uint8(opcode.Sar)}
+}
+
 func (pop) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
 	_ = stack.pop()
 	return nil
@@ -214,14 +477,16 @@ func (pop) hex() []uint8 {
 	return []uint8{uint8(opcode.Pop)}
 }
 
+// push reads its length-prefixed operand off the assembly stream (PUSH1..PUSH32
+// style) and pushes it as a word.
 func (push) Do(stack *stack, asm asmReader, _ *Memory, contract *CallFunc) error {
 	code := asm.next()
 	data, ok := code.(Data)
 	if !ok {
 		return ErrInvalidData
 	}
-	item := item(bytesToInt32(data.hex()))
-	stack.push(item)
+
+	stack.push(bytesToWord(data.hex()))
 
 	return nil
 }
@@ -230,8 +495,16 @@ func (push) hex() []uint8 {
 	return []uint8{uint8(opcode.Push)}
 }
 
-// TODO: implement me w/ test cases :-)
-func (mload) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
+// mload pops an offset and pushes the word loaded from memory at that offset.
+func (mload) Do(stack *stack, _ asmReader, memory *Memory, _ *CallFunc) error {
+	offset := stack.pop()
+
+	data, err := memory.Load(offset.toInt(), wordBytes)
+	if err != nil {
+		return err
+	}
+
+	stack.push(bytesToWord(data))
 	return nil
 }
 
@@ -239,45 +512,154 @@ func (mload) hex() []uint8 {
 	return []uint8{uint8(opcode.Mload)}
 }
 
-// TODO: implement me w/ test cases :-)
-func (mstore) Do(stack *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
-	return nil
+// mstore pops an offset then a value, and stores the value's word encoding at that offset in memory.
+func (mstore) Do(stack *stack, _ asmReader, memory *Memory, _ *CallFunc) error {
+	offset := stack.pop()
+	value := stack.pop()
+
+	return memory.Store(offset.toInt(), wordToBytes(value))
 }
 
 func (mstore) hex() []uint8 {
 	return []uint8{uint8(opcode.Mstore)}
 }
 
-func int32ToBytes(int32 int32) []byte {
-	byteSlice := make([]byte, 4)
-	binary.BigEndian.PutUint32(byteSlice, uint32(int32))
-	return byteSlice
+// jump pops a target position and moves the assembly cursor there. The
+// target must be a JUMPDEST, or asm.Seek returns ErrInvalidJumpDest.
+func (jump) Do(stack *stack, asm asmReader, _ *Memory, _ *CallFunc) error {
+	target := stack.pop()
+	return asm.Seek(target.toInt())
+}
+
+func (jump) hex() []uint8 {
+	return []uint8{uint8(opcode.Jump)}
+}
+
+// jumpi pops a target then a condition, and jumps to target only if condition is nonzero.
+func (jumpi) Do(stack *stack, asm asmReader, _ *Memory, _ *CallFunc) error {
+	target := stack.pop()
+	cond := stack.pop()
+
+	if cond.isZero() {
+		return nil
+	}
+
+	return asm.Seek(target.toInt())
+}
+
+func (jumpi) hex() []uint8 {
+	return []uint8{uint8(opcode.Jumpi)}
 }
 
-func bytesToInt32(bytes []byte) int32 {
-	int32 := int32(binary.BigEndian.Uint32(bytes))
-	return int32
+// jumpdest is a no-op marker; it only exists to be a valid jump target.
+func (jumpdest) Do(_ *stack, _ asmReader, _ *Memory, _ *CallFunc) error {
+	return nil
+}
+
+func (jumpdest) hex() []uint8 {
+	return []uint8{uint8(opcode.Jumpdest)}
 }
 
-func euclidean_div(a item, b item) (item, item) {
-	var q int32
-	var r int32
-	A := int32(a)
-	B := int32(b)
+// call pops (funcSelector, argsOffset, argsSize, retOffset, retSize), resolves
+// funcSelector via CallFunc.Resolver against the args read from memory, writes
+// the result to memory at retOffset, and pushes 1 on success or 0 on failure.
+func (call) Do(stack *stack, _ asmReader, memory *Memory, callFunc *CallFunc) error {
+	selector := stack.pop()
+	argsOffset := stack.pop().toInt()
+	argsSize := stack.pop().toInt()
+	retOffset := stack.pop().toInt()
+	retSize := stack.pop().toInt()
+
+	if callFunc == nil || callFunc.Resolver == nil {
+		stack.push(itemFromInt64(0))
+		return nil
+	}
+
+	args, err := memory.Load(argsOffset, argsSize)
+	if err != nil {
+		return err
+	}
+
+	result, err := callFunc.Resolver(wordToBytes(selector)[wordBytes-4:], args)
+	if err != nil {
+		stack.push(itemFromInt64(0))
+		return nil
+	}
 
-	if A < 0 && B > 0 {
-		q = int32(A/B) - 1
-		r = A - (B * q)
-	} else if A > 0 && B < 0 {
-		q = int32(A / B)
-		r = A - (B * q)
-	} else if A > 0 && B > 0 {
-		q = int32(A / B)
-		r = A - (B * q)
-	} else if A < 0 && B < 0 {
-		q = int32((A + B) / B)
-		r = A - (B * q)
+	if len(result) > retSize {
+		result = result[:retSize]
+	}
+	if err := memory.Store(retOffset, result); err != nil {
+		return err
 	}
 
-	return item(q), item(r)
+	stack.push(itemFromInt64(1))
+	return nil
+}
+
+func (call) hex() []uint8 {
+	return []uint8{uint8(opcode.Call)}
+}
+
+// ret pops (offset, size), copies that memory slice into CallFunc.ReturnData
+// and halts Execute cleanly via errNormalHalt.
+func (ret) Do(stack *stack, _ asmReader, memory *Memory, callFunc *CallFunc) error {
+	offset := stack.pop().toInt()
+	size := stack.pop().toInt()
+
+	data, err := memory.Load(offset, size)
+	if err != nil {
+		return err
+	}
+
+	if callFunc != nil {
+		callFunc.ReturnData = data
+	}
+
+	return errNormalHalt
+}
+
+func (ret) hex() []uint8 {
+	return []uint8{uint8(opcode.Return)}
+}
+
+// revert pops (offset, size), copies that memory slice into CallFunc.ReturnData
+// like ret, but halts Execute with ErrReverted so the caller can roll back
+// any gas/state changes.
+func (revert) Do(stack *stack, _ asmReader, memory *Memory, callFunc *CallFunc) error {
+	offset := stack.pop().toInt()
+	size := stack.pop().toInt()
+
+	data, err := memory.Load(offset, size)
+	if err != nil {
+		return err
+	}
+
+	if callFunc != nil {
+		callFunc.ReturnData = data
+	}
+
+	return ErrReverted
+}
+
+func (revert) hex() []uint8 {
+	return []uint8{uint8(opcode.Revert)}
+}
+
+// signedDivMod performs signed, truncating (T-division) quotient and
+// remainder of a / b, interpreting both as two's-complement 256-bit
+// integers. The remainder can be negative, matching Go's native % and the
+// EVM's SDIV/SMOD semantics; this is not Euclidean division.
+func signedDivMod(a item, b item) (item, item) {
+	A := a.signed()
+	B := b.signed()
+
+	if B.Sign() == 0 {
+		return itemFromInt64(0), itemFromInt64(0)
+	}
+
+	q, r := new(big.Int), new(big.Int)
+	q.QuoRem(A, B, r)
+
+	return fromSigned(q), fromSigned(r)
 }
@@ -0,0 +1,148 @@
+/*
+ * Copyright 2018 De-labtory
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCallInvokesResolverWithSelectorAndArgs(t *testing.T) {
+	mem := NewMemory()
+	if err := mem.Store(0, []byte{0x01, 0x02, 0x03, 0x04}); err != nil {
+		t.Fatalf("Store returned error: %v", err)
+	}
+
+	var gotSelector, gotArgs []byte
+	callFunc := &CallFunc{
+		Resolver: func(selector []byte, args []byte) ([]byte, error) {
+			gotSelector = selector
+			gotArgs = args
+			return []byte{0x09, 0x09, 0x09, 0x09}, nil
+		},
+	}
+
+	s := newStack()
+	// call pops (selector, argsOffset, argsSize, retOffset, retSize); push in
+	// reverse so selector ends up on top.
+	s.push(itemFromInt64(4))          // retSize
+	s.push(itemFromInt64(32))         // retOffset
+	s.push(itemFromInt64(4))          // argsSize
+	s.push(itemFromInt64(0))          // argsOffset
+	s.push(itemFromInt64(0xDEADBEEF)) // selector
+
+	if err := (call{}).Do(s, nil, mem, callFunc); err != nil {
+		t.Fatalf("call.Do returned error: %v", err)
+	}
+
+	if !bytes.Equal(gotSelector, []byte{0xDE, 0xAD, 0xBE, 0xEF}) {
+		t.Fatalf("Resolver selector = %x, want deadbeef", gotSelector)
+	}
+	if !bytes.Equal(gotArgs, []byte{0x01, 0x02, 0x03, 0x04}) {
+		t.Fatalf("Resolver args = %x, want 01020304", gotArgs)
+	}
+
+	if got := s.pop(); got.toInt() != 1 {
+		t.Fatalf("result = %d, want 1 (success)", got.toInt())
+	}
+
+	ret, err := mem.Load(32, 4)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if !bytes.Equal(ret, []byte{0x09, 0x09, 0x09, 0x09}) {
+		t.Fatalf("retOffset contents = %x, want 09090909", ret)
+	}
+}
+
+func TestCallPushesZeroOnResolverError(t *testing.T) {
+	mem := NewMemory()
+	callFunc := &CallFunc{
+		Resolver: func(selector []byte, args []byte) ([]byte, error) {
+			return nil, errors.New("resolver failed")
+		},
+	}
+
+	s := newStack()
+	s.push(itemFromInt64(0)) // retSize
+	s.push(itemFromInt64(0)) // retOffset
+	s.push(itemFromInt64(0)) // argsSize
+	s.push(itemFromInt64(0)) // argsOffset
+	s.push(itemFromInt64(0)) // selector
+
+	if err := (call{}).Do(s, nil, mem, callFunc); err != nil {
+		t.Fatalf("call.Do returned error: %v, want the Resolver error swallowed", err)
+	}
+	if got := s.pop(); got.toInt() != 0 {
+		t.Fatalf("result = %d, want 0 (failure)", got.toInt())
+	}
+}
+
+func TestCallPushesZeroWithNoResolver(t *testing.T) {
+	s := newStack()
+	s.push(itemFromInt64(0))
+	s.push(itemFromInt64(0))
+	s.push(itemFromInt64(0))
+	s.push(itemFromInt64(0))
+	s.push(itemFromInt64(0))
+
+	if err := (call{}).Do(s, nil, NewMemory(), &CallFunc{}); err != nil {
+		t.Fatalf("call.Do returned error: %v", err)
+	}
+	if got := s.pop(); got.toInt() != 0 {
+		t.Fatalf("result = %d, want 0 (no resolver)", got.toInt())
+	}
+}
+
+func TestReturnHaltsWithReturnData(t *testing.T) {
+	mem := NewMemory()
+	if err := mem.Store(5, []byte{7, 7, 7}); err != nil {
+		t.Fatalf("Store returned error: %v", err)
+	}
+
+	callFunc := &CallFunc{}
+	s := newStack()
+	s.push(itemFromInt64(3)) // size
+	s.push(itemFromInt64(5)) // offset
+
+	if err := (ret{}).Do(s, nil, mem, callFunc); err != errNormalHalt {
+		t.Fatalf("ret.Do err = %v, want errNormalHalt", err)
+	}
+	if !bytes.Equal(callFunc.ReturnData, []byte{7, 7, 7}) {
+		t.Fatalf("ReturnData = %x, want 070707", callFunc.ReturnData)
+	}
+}
+
+func TestRevertHaltsWithReturnData(t *testing.T) {
+	mem := NewMemory()
+	if err := mem.Store(5, []byte{9, 9}); err != nil {
+		t.Fatalf("Store returned error: %v", err)
+	}
+
+	callFunc := &CallFunc{}
+	s := newStack()
+	s.push(itemFromInt64(2)) // size
+	s.push(itemFromInt64(5)) // offset
+
+	if err := (revert{}).Do(s, nil, mem, callFunc); err != ErrReverted {
+		t.Fatalf("revert.Do err = %v, want ErrReverted", err)
+	}
+	if !bytes.Equal(callFunc.ReturnData, []byte{9, 9}) {
+		t.Fatalf("ReturnData = %x, want 0909", callFunc.ReturnData)
+	}
+}